@@ -0,0 +1,67 @@
+package service
+
+import "errors"
+
+// Action is a unit of instruction execution: it consumes a Context and
+// produces the state changes, and any coins, that the instruction causes.
+// SpawnAction, InvokeAction, and DeleteAction wrap the three possible
+// shapes of an Instruction. RegisterContract lets a contract supply its
+// own Action for its contract ID, so the "how do I apply myself" logic
+// lives next to the contract instead of in one large switch in the
+// service, and so per-contract middleware (gas accounting, tracing,
+// access logs) can wrap a contract's Action without the contract
+// needing to know about it.
+type Action interface {
+	// Execute runs the action against ctx and returns the resulting
+	// state changes and any coins that must be returned to the caller.
+	Execute(ctx Context) ([]StateChange, []Coin, error)
+}
+
+// SpawnAction is the Action for an Instruction carrying a Spawn.
+type SpawnAction struct {
+	Instruction Instruction
+	Spawn       Spawn
+}
+
+// InvokeAction is the Action for an Instruction carrying an Invoke.
+type InvokeAction struct {
+	Instruction Instruction
+	Invoke      Invoke
+}
+
+// DeleteAction is the Action for an Instruction carrying a Delete.
+type DeleteAction struct {
+	Instruction Instruction
+}
+
+// NewAction builds the concrete Action for instr. It is what
+// ExecuteInstruction passes to the factory registered under
+// RegisterContract for the instruction's contract ID.
+func NewAction(instr Instruction) (Action, error) {
+	switch {
+	case instr.Spawn != nil:
+		return SpawnAction{Instruction: instr, Spawn: *instr.Spawn}, nil
+	case instr.Invoke != nil:
+		return InvokeAction{Instruction: instr, Invoke: *instr.Invoke}, nil
+	case instr.Delete != nil:
+		return DeleteAction{Instruction: instr}, nil
+	default:
+		return nil, errors.New("service: instruction has neither spawn, invoke nor delete")
+	}
+}
+
+// Execute implements Action. A bare SpawnAction has no contract-specific
+// behaviour; RegisterContract's factory replaces it with one that does.
+func (a SpawnAction) Execute(ctx Context) ([]StateChange, []Coin, error) {
+	return nil, nil, errors.New("service: contract " + a.Spawn.ContractID + " did not register a spawn handler")
+}
+
+// Execute implements Action.
+func (a InvokeAction) Execute(ctx Context) ([]StateChange, []Coin, error) {
+	return nil, nil, errors.New("service: invoke " + a.Invoke.Command + " has no registered handler")
+}
+
+// Execute implements Action.
+func (a DeleteAction) Execute(ctx Context) ([]StateChange, []Coin, error) {
+	return nil, nil, errors.New("service: delete has no registered handler")
+}
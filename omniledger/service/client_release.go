@@ -0,0 +1,93 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/dedis/cothority"
+	"github.com/dedis/cothority/omniledger/darc"
+	"github.com/dedis/onet"
+	"github.com/dedis/onet/network"
+)
+
+func init() {
+	network.RegisterMessages(CurrentRelease{}, CurrentReleaseReply{})
+}
+
+// ServiceName is the name under which onet looks up the omniledger
+// service in a roster.
+const ServiceName = "OmniLedger"
+
+// PROTOSTART
+//
+// option java_package = "ch.epfl.dedis.proto";
+// option java_outer_classname = "ReleaseProto";
+
+// CurrentRelease asks a node for the release currently published under
+// the release contract instance spawned on DarcID.
+type CurrentRelease struct {
+	DarcID darc.ID
+}
+
+// CurrentReleaseReply returns the release tuple together with the proof
+// that it is included in the collection at the replying node's latest
+// skipblock.
+type CurrentReleaseReply struct {
+	Release Release
+	Proof   []byte
+}
+
+// Client talks to a running omniledger service to query the release
+// oracle contract.
+type Client struct {
+	*onet.Client
+}
+
+// NewClient returns a Client ready to contact an omniledger service.
+func NewClient() *Client {
+	return &Client{Client: onet.NewClient(cothority.Suite, ServiceName)}
+}
+
+// CurrentRelease returns the release currently published under the
+// release contract instance spawned on darcID, asking the first node of
+// roster.
+func (c *Client) CurrentRelease(roster *onet.Roster, darcID darc.ID) (*CurrentReleaseReply, error) {
+	reply := &CurrentReleaseReply{}
+	if err := c.SendProtobuf(roster.List[0], &CurrentRelease{DarcID: darcID}, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// LookupCurrentRelease is the handler behind the CurrentRelease RPC: it
+// looks up the release contract instance spawned on req.DarcID in coll
+// and returns its current Release together with the proof that this
+// state is included in coll. An omniledger Service registers it against
+// incoming CurrentRelease requests with
+// s.RegisterHandler(s.CurrentRelease), where Service.CurrentRelease
+// resolves the CollectionView for req's skipchain and calls this
+// function -- that registration lives in this package's service.go,
+// which, like the CollectionView type this function takes, is not part
+// of this snapshot.
+func LookupCurrentRelease(coll CollectionView, req *CurrentRelease) (*CurrentReleaseReply, error) {
+	oid := ObjectID{DarcID: req.DarcID}
+	contractID, buf, err := readObject(coll, oid)
+	if err != nil {
+		return nil, err
+	}
+	if contractID != ContractReleaseID {
+		return nil, errors.New("service: object is not a release contract instance")
+	}
+	_, msg, err := network.Unmarshal(buf, cothority.Suite)
+	if err != nil {
+		return nil, err
+	}
+	cfg, ok := msg.(*ReleaseConfig)
+	if !ok {
+		return nil, errors.New("service: corrupt release contract state")
+	}
+	proof, err := coll.Get(oid.Slice()).Proof()
+	if err != nil {
+		return nil, err
+	}
+	return &CurrentReleaseReply{Release: cfg.Current, Proof: proof}, nil
+}
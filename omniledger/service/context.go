@@ -0,0 +1,110 @@
+package service
+
+import (
+	"time"
+
+	"github.com/dedis/cothority/omniledger/collection"
+	"github.com/dedis/cothority/omniledger/darc"
+)
+
+// Context is the read-only view of chain state, signer identities, and
+// instruction arguments that a contract needs in order to execute. It
+// replaces the previous pattern of every contract reaching into a
+// CollectionView and parsing the raw bytes stored there itself.
+type Context interface {
+	// Read returns the contract that owns oid and its current state, in
+	// the same shape Instruction.GetContractState used to return them.
+	Read(oid ObjectID) (contractID string, state []byte, err error)
+	// Signers returns the identities that signed the instruction this
+	// Context was built for.
+	Signers() []darc.Identity
+	// Arg looks up a named argument of the Spawn or Invoke carried by
+	// the instruction this Context was built for. It returns nil if the
+	// instruction has no such argument, or is a Delete.
+	Arg(name string) []byte
+	// Now returns the time at which the enclosing block is being built.
+	Now() time.Time
+	// ChainID identifies the skipchain this Context belongs to.
+	ChainID() []byte
+	// Coins returns the coins carried into this instruction from the one
+	// before it in the enclosing ClientTransaction, as returned by that
+	// instruction's Action.Execute. It is empty for the first instruction
+	// of a ClientTransaction.
+	Coins() []Coin
+}
+
+// execContext is the Context implementation used while a block is being
+// built or verified by this service.
+type execContext struct {
+	coll    CollectionView
+	instr   Instruction
+	now     time.Time
+	chainID []byte
+	coins   []Coin
+}
+
+// NewContext builds the Context for instr against coll, to be executed at
+// now on the skipchain identified by chainID, carrying forward coins from
+// the previous instruction in the enclosing ClientTransaction (nil if
+// instr is the first one). ExecuteClientTransaction is the caller that
+// threads coins between successive instructions this way.
+func NewContext(coll CollectionView, instr Instruction, now time.Time, chainID []byte, coins []Coin) Context {
+	return execContext{coll: coll, instr: instr, now: now, chainID: chainID, coins: coins}
+}
+
+// Read implements Context.
+func (c execContext) Read(oid ObjectID) (string, []byte, error) {
+	return readObject(c.coll, oid)
+}
+
+// Signers implements Context.
+func (c execContext) Signers() []darc.Identity {
+	ids := make([]darc.Identity, len(c.instr.Signatures))
+	for i, s := range c.instr.Signatures {
+		ids[i] = s.Signer
+	}
+	return ids
+}
+
+// Arg implements Context.
+func (c execContext) Arg(name string) []byte {
+	switch {
+	case c.instr.Spawn != nil:
+		return c.instr.Spawn.Args.Search(name)
+	case c.instr.Invoke != nil:
+		return c.instr.Invoke.Args.Search(name)
+	default:
+		return nil
+	}
+}
+
+// Now implements Context.
+func (c execContext) Now() time.Time { return c.now }
+
+// ChainID implements Context.
+func (c execContext) ChainID() []byte { return c.chainID }
+
+// Coins implements Context.
+func (c execContext) Coins() []Coin { return c.coins }
+
+// readObject looks up the contract kind and state stored for oid in coll.
+// It is the part of the old Instruction.GetContractState that applies to
+// instructions targeting an existing object; Spawn instructions carry
+// their contract ID directly and never call this.
+func readObject(coll CollectionView, oid ObjectID) (contractID string, state []byte, err error) {
+	kv := coll.Get(oid.Slice())
+	var record collection.Record
+	record, err = kv.Record()
+	if err != nil {
+		return
+	}
+	var cv []interface{}
+	cv, err = record.Values()
+	if err != nil {
+		return
+	}
+	// TODO cast might panic
+	contractID = string(cv[1].([]byte))
+	state = cv[0].([]byte)
+	return
+}
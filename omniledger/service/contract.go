@@ -0,0 +1,117 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ContractFactory builds the Action that implements one contract's
+// behaviour for a given instruction, wrapping the bare Action that
+// NewAction produced from the instruction alone. Most contracts ignore
+// base and build their own state change logic directly from ctx; the
+// parameter exists so middleware can wrap a contract's Action without
+// the contract needing to know about it.
+type ContractFactory func(base Action) Action
+
+var contractRegistry = struct {
+	sync.Mutex
+	factories map[string]ContractFactory
+}{factories: make(map[string]ContractFactory)}
+
+// RegisterContract makes a contract's Action factory available under
+// contractID. It is typically called from an init function. Registering
+// the same contractID twice panics, the same way the former function-map
+// registration caught an accidental double registration.
+func RegisterContract(contractID string, factory ContractFactory) {
+	contractRegistry.Lock()
+	defer contractRegistry.Unlock()
+	if _, ok := contractRegistry.factories[contractID]; ok {
+		panic("service: contract " + contractID + " registered twice")
+	}
+	contractRegistry.factories[contractID] = factory
+}
+
+// ContractFunc is the pre-refactor contract signature: a function that
+// reaches directly into a CollectionView and the raw instruction.
+type ContractFunc func(cdb CollectionView, instr Instruction, coins []Coin) ([]StateChange, []Coin, error)
+
+// RegisterContractFunc adapts a legacy ContractFunc into the
+// Context/Action API and registers it under contractID via
+// RegisterContract, so contracts that have not migrated yet keep
+// compiling and working unchanged. New contracts should call
+// RegisterContract directly instead.
+func RegisterContractFunc(contractID string, fn ContractFunc) {
+	RegisterContract(contractID, func(base Action) Action {
+		return legacyContractAction{fn: fn}
+	})
+}
+
+// legacyContractAction recovers the CollectionView and Instruction a
+// ContractFunc expects from the execContext it is handed; it only works
+// when Execute is called with a Context built by NewContext.
+type legacyContractAction struct {
+	fn ContractFunc
+}
+
+// Execute implements Action.
+func (a legacyContractAction) Execute(ctx Context) ([]StateChange, []Coin, error) {
+	ec, ok := ctx.(execContext)
+	if !ok {
+		return nil, nil, errors.New("service: legacy contracts require a Context built by NewContext")
+	}
+	return a.fn(ec.coll, ec.instr, ctx.Coins())
+}
+
+// ExecuteInstruction resolves the contract responsible for instr from
+// ctx, builds the Action registered for it under RegisterContract, and
+// runs it. It is the typed-API replacement for looking up a contract in
+// a raw function map.
+func ExecuteInstruction(ctx Context, instr Instruction) ([]StateChange, []Coin, error) {
+	var contractID string
+	if instr.Spawn != nil {
+		contractID = instr.Spawn.ContractID
+	} else {
+		var err error
+		contractID, _, err = ctx.Read(instr.ObjectID)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	contractRegistry.Lock()
+	factory, ok := contractRegistry.factories[contractID]
+	contractRegistry.Unlock()
+	if !ok {
+		return nil, nil, errors.New("service: no contract registered for " + contractID)
+	}
+
+	base, err := NewAction(instr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return factory(base).Execute(ctx)
+}
+
+// ExecuteClientTransaction runs every instruction of ct through
+// ExecuteInstruction in order, building each instruction's Context with
+// NewContext and threading the coins one Action.Execute returns as the
+// next instruction's incoming coins -- the same sequencing legacy
+// ContractFuncs relied on when a caller forwarded coins between chained
+// invocations by hand. If any instruction fails, no state changes from ct
+// are returned, matching ClientTransaction's own doc comment that either
+// all of its instructions apply or none do.
+func ExecuteClientTransaction(coll CollectionView, ct ClientTransaction, now time.Time, chainID []byte) ([]StateChange, error) {
+	var all []StateChange
+	var coins []Coin
+	for _, instr := range ct.Instructions {
+		ctx := NewContext(coll, instr, now, chainID, coins)
+		sc, outCoins, err := ExecuteInstruction(ctx, instr)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, sc...)
+		coins = outCoins
+	}
+	return all, nil
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/dedis/cothority/omniledger/darc"
+)
+
+// ContractDarcID is the contract ID that owns darc objects. It is the
+// worked example for the Context/Action API: the one contract migrated
+// off raw CollectionView access when RegisterContract was introduced.
+const ContractDarcID = "darc"
+
+func init() {
+	RegisterContract(ContractDarcID, func(base Action) Action {
+		return darcContractAction{base: base}
+	})
+}
+
+// darcContractAction implements the darc contract's invoke:evolve command
+// using the typed Context/Action API, in place of the raw CollectionView
+// access that ToDarcRequest's "_evolve" special case used to require of
+// every caller.
+type darcContractAction struct {
+	base Action
+}
+
+// Execute implements Action.
+func (a darcContractAction) Execute(ctx Context) ([]StateChange, []Coin, error) {
+	inv, ok := a.base.(InvokeAction)
+	if !ok || inv.Invoke.Command != "evolve" {
+		return a.base.Execute(ctx)
+	}
+
+	newDarcBuf := ctx.Arg("darc")
+	if newDarcBuf == nil {
+		return nil, nil, errors.New("service: evolve needs a \"darc\" argument")
+	}
+	newDarc, err := darc.NewFromProtobuf(newDarcBuf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, oldState, err := ctx.Read(inv.Instruction.ObjectID)
+	if err != nil {
+		return nil, nil, err
+	}
+	oldDarc, err := darc.NewFromProtobuf(oldState)
+	if err != nil {
+		return nil, nil, err
+	}
+	if newDarc.GetBaseID() != nil && oldDarc.GetBaseID() != nil &&
+		string(newDarc.GetBaseID()) != string(oldDarc.GetBaseID()) {
+		return nil, nil, errors.New("service: evolved darc has a different base ID")
+	}
+
+	sc := NewStateChange(Update, inv.Instruction.ObjectID, ContractDarcID, newDarcBuf)
+	return []StateChange{sc}, nil, nil
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/dedis/cothority"
+	"github.com/dedis/cothority/omniledger/darc"
+	"github.com/dedis/onet/network"
+)
+
+func init() {
+	network.RegisterMessages(ReleaseVersion{}, Release{}, ReleaseConfig{})
+	RegisterContract(ContractReleaseID, func(base Action) Action {
+		return releaseContractAction{base: base}
+	})
+}
+
+// ContractReleaseID is the contract ID of the release oracle: a contract
+// that stores signed (version, commit) tuples so cothority nodes can
+// discover, and verifiably agree on, the recommended binary to run
+// without an out-of-band channel.
+const ContractReleaseID = "release"
+
+// releaseHistoryLen is how many past releases are kept in ReleaseConfig.History
+// alongside the current one.
+const releaseHistoryLen = 8
+
+// ReleaseVersion is a semantic version tuple, encoded as four uint32s so
+// it round-trips through both protobuf and the rlp codec unchanged.
+type ReleaseVersion struct {
+	Major uint32
+	Minor uint32
+	Patch uint32
+	Build uint32
+}
+
+// Less reports whether v is an earlier version than other.
+func (v ReleaseVersion) Less(other ReleaseVersion) bool {
+	switch {
+	case v.Major != other.Major:
+		return v.Major < other.Major
+	case v.Minor != other.Minor:
+		return v.Minor < other.Minor
+	case v.Patch != other.Patch:
+		return v.Patch < other.Patch
+	default:
+		return v.Build < other.Build
+	}
+}
+
+// Release is one published (version, commit) tuple.
+type Release struct {
+	Version ReleaseVersion
+	// Commit is the 20-byte hash (e.g. a git commit SHA-1) of the
+	// release that Version refers to.
+	Commit [20]byte
+}
+
+// ReleaseConfig is the state the release contract keeps for one
+// instance: the maintainer set allowed to publish, the threshold of
+// maintainer signatures a publish needs, the current release, and a
+// short history of the releases that preceded it.
+type ReleaseConfig struct {
+	Maintainers []darc.Identity
+	Threshold   int
+	Current     Release
+	History     []Release
+}
+
+// verifyMultiSig checks that at least cfg.Threshold of cfg.Maintainers
+// signed digest, each signature appearing at most once. digest is the
+// instruction's darc request hash (see instructionDigest), the same
+// digest SignBy signs an instruction's signatures against, so a
+// maintainer authorizes a publish or revoke the same way any other darc
+// action is authorized -- no separate out-of-band signing step is
+// needed.
+func (cfg ReleaseConfig) verifyMultiSig(digest []byte, sigs []darc.Signature) error {
+	seen := make(map[string]bool)
+	valid := 0
+	for _, sig := range sigs {
+		key := sig.Signer.String()
+		if seen[key] {
+			continue
+		}
+		isMaintainer := false
+		for _, m := range cfg.Maintainers {
+			if m.Equal(&sig.Signer) {
+				isMaintainer = true
+				break
+			}
+		}
+		if !isMaintainer {
+			continue
+		}
+		if err := sig.Signer.Verify(digest, sig.Signature); err != nil {
+			continue
+		}
+		seen[key] = true
+		valid++
+	}
+	if valid < cfg.Threshold {
+		return errors.New("service: release publish has only " +
+			"enough maintainer signatures to reach a partial quorum")
+	}
+	return nil
+}
+
+// releaseContractAction implements the release contract's spawn, publish
+// and revoke actions using the Context/Action API.
+type releaseContractAction struct {
+	base Action
+}
+
+// Execute implements Action.
+func (a releaseContractAction) Execute(ctx Context) ([]StateChange, []Coin, error) {
+	switch act := a.base.(type) {
+	case SpawnAction:
+		if act.Spawn.ContractID != ContractReleaseID {
+			return a.base.Execute(ctx)
+		}
+		return a.spawn(ctx, act)
+	case InvokeAction:
+		switch act.Invoke.Command {
+		case "publish":
+			return a.publish(ctx, act)
+		case "revoke":
+			return a.revoke(ctx, act)
+		}
+	}
+	return a.base.Execute(ctx)
+}
+
+func (a releaseContractAction) spawn(ctx Context, act SpawnAction) ([]StateChange, []Coin, error) {
+	thresholdBuf := act.Spawn.Args.Search("threshold")
+	if len(thresholdBuf) != 4 {
+		return nil, nil, errors.New("service: release spawn needs a 4-byte \"threshold\" argument")
+	}
+	threshold := int(binary.BigEndian.Uint32(thresholdBuf))
+
+	maintainerBuf := act.Spawn.Args.Search("maintainers")
+	maintainers, err := darc.NewIdentityListFromProtobuf(maintainerBuf)
+	if err != nil {
+		return nil, nil, err
+	}
+	if threshold <= 0 || threshold > len(maintainers) {
+		return nil, nil, errors.New("service: release threshold must be between 1 and len(maintainers)")
+	}
+
+	cfg := ReleaseConfig{Maintainers: maintainers, Threshold: threshold}
+	buf, err := network.Marshal(&cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	oid := act.Instruction.DeriveID("")
+	return []StateChange{NewStateChange(Create, oid, ContractReleaseID, buf)}, nil, nil
+}
+
+func (a releaseContractAction) publish(ctx Context, act InvokeAction) ([]StateChange, []Coin, error) {
+	cfg, err := a.readConfig(ctx, act)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	versionBuf := act.Invoke.Args.Search("version")
+	if len(versionBuf) != 16 {
+		return nil, nil, errors.New("service: publish needs a 16-byte \"version\" argument")
+	}
+	var rel Release
+	rel.Version.Major = binary.BigEndian.Uint32(versionBuf[0:4])
+	rel.Version.Minor = binary.BigEndian.Uint32(versionBuf[4:8])
+	rel.Version.Patch = binary.BigEndian.Uint32(versionBuf[8:12])
+	rel.Version.Build = binary.BigEndian.Uint32(versionBuf[12:16])
+
+	commitBuf := act.Invoke.Args.Search("commit")
+	if len(commitBuf) != 20 {
+		return nil, nil, errors.New("service: publish needs a 20-byte \"commit\" argument")
+	}
+	copy(rel.Commit[:], commitBuf)
+
+	digest, err := instructionDigest(ctx, act.Instruction)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cfg.verifyMultiSig(digest, act.Instruction.Signatures); err != nil {
+		return nil, nil, err
+	}
+
+	cfg.History = append([]Release{cfg.Current}, cfg.History...)
+	if len(cfg.History) > releaseHistoryLen {
+		cfg.History = cfg.History[:releaseHistoryLen]
+	}
+	cfg.Current = rel
+	warnIfOutdated(rel.Version)
+
+	buf, err := network.Marshal(&cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []StateChange{NewStateChange(Update, act.Instruction.ObjectID, ContractReleaseID, buf)}, nil, nil
+}
+
+func (a releaseContractAction) revoke(ctx Context, act InvokeAction) ([]StateChange, []Coin, error) {
+	cfg, err := a.readConfig(ctx, act)
+	if err != nil {
+		return nil, nil, err
+	}
+	digest, err := instructionDigest(ctx, act.Instruction)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cfg.verifyMultiSig(digest, act.Instruction.Signatures); err != nil {
+		return nil, nil, err
+	}
+	if len(cfg.History) == 0 {
+		cfg.Current = Release{}
+	} else {
+		cfg.Current = cfg.History[0]
+		cfg.History = cfg.History[1:]
+	}
+	buf, err := network.Marshal(&cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []StateChange{NewStateChange(Update, act.Instruction.ObjectID, ContractReleaseID, buf)}, nil, nil
+}
+
+// instructionDigest returns the digest instr's signatures are checked
+// against when instr is authorized the normal way, via SignBy: the hash
+// of the darc.Request that ToDarcRequestAtHeight builds for it. Using
+// this digest here, instead of a release-specific one, means a
+// maintainer signs a publish or revoke exactly the way any other
+// instruction gets signed -- there is no second signing convention a
+// client needs to implement, and no mismatch with whatever darc-level
+// check also verifies instr's signatures before the contract ever runs.
+//
+// Resolving the signer from ctx.ChainID(), the chain this contract is
+// actually executing on, rather than from instr.ChainID, is what keeps
+// this replay-safe: a maintainer quorum authorized for one skipchain
+// cannot be replayed onto another one just because the replayed
+// instruction claims to be for it.
+func instructionDigest(ctx Context, instr Instruction) ([]byte, error) {
+	req, err := instr.ToDarcRequestAtHeight(ChainConfig{ChainID: ctx.ChainID()}, 0)
+	if err != nil {
+		return nil, err
+	}
+	return req.Hash(), nil
+}
+
+func (a releaseContractAction) readConfig(ctx Context, act InvokeAction) (ReleaseConfig, error) {
+	contractID, buf, err := ctx.Read(act.Instruction.ObjectID)
+	if err != nil {
+		return ReleaseConfig{}, err
+	}
+	if contractID != ContractReleaseID {
+		return ReleaseConfig{}, errors.New("service: object is not a release contract instance")
+	}
+	_, msg, err := network.Unmarshal(buf, cothority.Suite)
+	if err != nil {
+		return ReleaseConfig{}, err
+	}
+	cfg, ok := msg.(*ReleaseConfig)
+	if !ok {
+		return ReleaseConfig{}, errors.New("service: corrupt release contract state")
+	}
+	return *cfg, nil
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dedis/cothority/omniledger/darc"
+	"github.com/stretchr/testify/require"
+)
+
+// Full spawn/publish/revoke/LookupCurrentRelease coverage needs a working
+// CollectionView, which -- like the rest of the real service package --
+// is not part of this snapshot (see LookupCurrentRelease's doc comment).
+// What is testable here, and is exactly the security-critical logic the
+// release contract is supposed to enforce, is verifyMultiSig's threshold
+// and duplicate-signer rules, and instructionDigest's chain binding.
+
+func TestReleaseVerifyMultiSigRequiresThreshold(t *testing.T) {
+	m1 := darc.NewSignerEd25519(nil, nil)
+	m2 := darc.NewSignerEd25519(nil, nil)
+	cfg := ReleaseConfig{
+		Maintainers: []darc.Identity{m1.Identity(), m2.Identity()},
+		Threshold:   2,
+	}
+	digest := []byte("release digest")
+
+	sig1, err := m1.Sign(digest)
+	require.NoError(t, err)
+
+	err = cfg.verifyMultiSig(digest, []darc.Signature{{Signer: m1.Identity(), Signature: sig1}})
+	require.Error(t, err, "one of two required maintainer signatures must not reach quorum")
+
+	sig2, err := m2.Sign(digest)
+	require.NoError(t, err)
+
+	err = cfg.verifyMultiSig(digest, []darc.Signature{
+		{Signer: m1.Identity(), Signature: sig1},
+		{Signer: m2.Identity(), Signature: sig2},
+	})
+	require.NoError(t, err, "two of two required maintainer signatures must reach quorum")
+}
+
+func TestReleaseVerifyMultiSigRejectsDuplicateSigner(t *testing.T) {
+	m1 := darc.NewSignerEd25519(nil, nil)
+	m2 := darc.NewSignerEd25519(nil, nil)
+	cfg := ReleaseConfig{
+		Maintainers: []darc.Identity{m1.Identity(), m2.Identity()},
+		Threshold:   2,
+	}
+	digest := []byte("release digest")
+
+	sig1, err := m1.Sign(digest)
+	require.NoError(t, err)
+
+	err = cfg.verifyMultiSig(digest, []darc.Signature{
+		{Signer: m1.Identity(), Signature: sig1},
+		{Signer: m1.Identity(), Signature: sig1},
+	})
+	require.Error(t, err, "the same maintainer's signature counted twice must not substitute for a second maintainer")
+}
+
+func TestReleaseVerifyMultiSigIgnoresNonMaintainerSignatures(t *testing.T) {
+	m1 := darc.NewSignerEd25519(nil, nil)
+	outsider := darc.NewSignerEd25519(nil, nil)
+	cfg := ReleaseConfig{
+		Maintainers: []darc.Identity{m1.Identity()},
+		Threshold:   1,
+	}
+	digest := []byte("release digest")
+
+	sig, err := outsider.Sign(digest)
+	require.NoError(t, err)
+
+	err = cfg.verifyMultiSig(digest, []darc.Signature{{Signer: outsider.Identity(), Signature: sig}})
+	require.Error(t, err, "a valid signature from a non-maintainer must not count toward the threshold")
+}
+
+// TestInstructionDigestRejectsChainIDMismatch checks that the release
+// contract's digest resolution inherits ToDarcRequestAtHeight's replay
+// protection: a maintainer quorum authorized against one skipchain's
+// ChainConfig must not verify when the contract is executing on another.
+func TestInstructionDigestRejectsChainIDMismatch(t *testing.T) {
+	signer := darc.NewSignerEd25519(nil, nil)
+
+	var chainA, chainB [32]byte
+	chainA[0] = 0xaa
+	chainB[0] = 0xbb
+
+	instr := Instruction{
+		ObjectID: ObjectID{DarcID: darc.ID("release-instance")},
+		ChainID:  chainA,
+	}
+	require.NoError(t, instr.SignBy(signer))
+
+	ctxA := NewContext(nil, instr, time.Time{}, chainA[:], nil)
+	_, err := instructionDigest(ctxA, instr)
+	require.NoError(t, err, "an instruction signed for chain A must resolve a digest when executed on chain A")
+
+	ctxB := NewContext(nil, instr, time.Time{}, chainB[:], nil)
+	_, err = instructionDigest(ctxB, instr)
+	require.Error(t, err, "an instruction signed for chain A must be rejected, not silently re-verified, when executed on chain B")
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dedis/cothority/omniledger/darc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteInstructionDispatchesToRegisteredContract(t *testing.T) {
+	called := false
+	RegisterContract("test-spy", func(base Action) Action {
+		return spyAction{base: base, called: &called}
+	})
+
+	instr := Instruction{
+		ObjectID: ObjectID{DarcID: darc.ID("d")},
+		Spawn:    &Spawn{ContractID: "test-spy"},
+	}
+	ctx := NewContext(nil, instr, time.Time{}, nil, nil)
+
+	_, _, err := ExecuteInstruction(ctx, instr)
+	require.NoError(t, err)
+	require.True(t, called, "ExecuteInstruction must invoke the factory registered for the instruction's contract")
+}
+
+type spyAction struct {
+	base   Action
+	called *bool
+}
+
+func (a spyAction) Execute(ctx Context) ([]StateChange, []Coin, error) {
+	*a.called = true
+	return nil, nil, nil
+}
+
+// TestExecuteClientTransactionThreadsCoins checks that
+// ExecuteClientTransaction carries the coins one instruction's Action
+// returns into the next instruction's Context, the behavior
+// legacyContractAction's fix to Execute (see contract.go) depends on.
+func TestExecuteClientTransactionThreadsCoins(t *testing.T) {
+	var seen [][]Coin
+	RegisterContract("test-coin-echo", func(base Action) Action {
+		return coinEchoAction{seen: &seen}
+	})
+
+	ct := ClientTransaction{Instructions: Instructions{
+		{ObjectID: ObjectID{DarcID: darc.ID("d")}, Spawn: &Spawn{ContractID: "test-coin-echo"}},
+		{ObjectID: ObjectID{DarcID: darc.ID("d")}, Spawn: &Spawn{ContractID: "test-coin-echo"}},
+	}}
+
+	_, err := ExecuteClientTransaction(nil, ct, time.Time{}, nil)
+	require.NoError(t, err)
+	require.Len(t, seen, 2)
+	require.Empty(t, seen[0], "the first instruction should receive no coins")
+	require.Len(t, seen[1], 1, "the second instruction should receive the coin the first one returned")
+}
+
+type coinEchoAction struct{ seen *[][]Coin }
+
+func (a coinEchoAction) Execute(ctx Context) ([]StateChange, []Coin, error) {
+	*a.seen = append(*a.seen, ctx.Coins())
+	return nil, []Coin{{Value: 1}}, nil
+}
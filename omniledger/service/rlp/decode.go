@@ -0,0 +1,383 @@
+package rlp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// ErrElemTooLarge is returned when a list or string header claims more
+// bytes than remain in the enclosing list.
+var ErrElemTooLarge = errors.New("rlp: element is larger than containing list")
+
+// listPos tracks how many payload bytes remain to be read from the list
+// the Stream is currently inside, and how many bytes (header plus
+// payload) that list occupies in its own enclosing list, if any.
+type listPos struct {
+	remaining int64
+	size      int64
+}
+
+// Stream reads a sequence of RLP-encoded values from an underlying
+// io.Reader. It is stack based: List pushes a new listPos tracking that
+// list's remaining payload bytes, and ListEnd pops it after checking
+// every byte of the list was consumed.
+type Stream struct {
+	r     io.Reader
+	stack []listPos
+}
+
+// NewStream returns a Stream reading from r. inputLimit is accepted for
+// parity with decoders that bound total input size; this Stream ignores
+// it, and bounds reads only through each list's remaining-byte
+// accounting.
+func NewStream(r io.Reader, inputLimit uint64) *Stream {
+	return &Stream{r: r}
+}
+
+// List enters a list, returning the number of payload bytes it holds.
+// Every read performed until the matching ListEnd is accounted against
+// this size, so ListEnd can detect a short or long read. The list's own
+// total size (header plus payload) is charged against the enclosing
+// list, if any, only once ListEnd pops it back off again - until then,
+// reads inside the nested list must not double-debit the parent.
+func (s *Stream) List() (uint64, error) {
+	b, err := s.readByte()
+	if err != nil {
+		return 0, err
+	}
+	if b < 0xc0 {
+		return 0, fmt.Errorf("rlp: expected list, got string (prefix 0x%x)", b)
+	}
+	headerLen, size, err := s.readHeader(b, 0xc0, 0xf7)
+	if err != nil {
+		return 0, err
+	}
+	s.stack = append(s.stack, listPos{remaining: int64(size), size: int64(headerLen) + 1 + int64(size)})
+	return size, nil
+}
+
+// ListEnd leaves the list most recently entered with List, erroring if
+// not all of its payload bytes were consumed. Popping the list charges
+// its full encoded size against the list now on top of the stack (the
+// enclosing one, if any), so siblings that follow a nested struct or
+// list field stay aligned.
+func (s *Stream) ListEnd() error {
+	if len(s.stack) == 0 {
+		return errors.New("rlp: ListEnd called outside of a list")
+	}
+	top := s.stack[len(s.stack)-1]
+	if top.remaining != 0 {
+		return fmt.Errorf("rlp: %d unread bytes at end of list", top.remaining)
+	}
+	s.stack = s.stack[:len(s.stack)-1]
+	s.account(top.size)
+	return nil
+}
+
+// More reports whether the list most recently entered with List has any
+// payload bytes left to read.
+func (s *Stream) More() bool {
+	if len(s.stack) == 0 {
+		return false
+	}
+	return s.stack[len(s.stack)-1].remaining > 0
+}
+
+// Bytes reads a string value.
+func (s *Stream) Bytes() ([]byte, error) {
+	b, err := s.readByte()
+	if err != nil {
+		return nil, err
+	}
+	if b < 0x80 {
+		s.account(1)
+		return []byte{b}, nil
+	}
+	if b >= 0xc0 {
+		return nil, fmt.Errorf("rlp: expected string, got list (prefix 0x%x)", b)
+	}
+	headerLen, size, err := s.readHeader(b, 0x80, 0xb7)
+	if err != nil {
+		return nil, err
+	}
+	var out []byte
+	if size > 0 {
+		out = make([]byte, size)
+		if _, err := io.ReadFull(s.r, out); err != nil {
+			return nil, err
+		}
+	}
+	s.account(int64(headerLen) + 1 + int64(size))
+	if size == 1 && out[0] < 0x80 {
+		return nil, ErrNonCanonicalInt
+	}
+	return out, nil
+}
+
+// Uint64 reads a string value and interprets it as a big-endian unsigned
+// integer, rejecting a leading zero byte.
+func (s *Stream) Uint64() (uint64, error) {
+	b, err := s.Bytes()
+	if err != nil {
+		return 0, err
+	}
+	if len(b) > 8 {
+		return 0, fmt.Errorf("rlp: uint64 overflow, %d bytes", len(b))
+	}
+	if len(b) > 0 && b[0] == 0 {
+		return 0, ErrNonCanonicalInt
+	}
+	var x uint64
+	for _, c := range b {
+		x = x<<8 | uint64(c)
+	}
+	return x, nil
+}
+
+// Decode reads a single RLP value into val, which must be a non-nil
+// pointer. Types implementing Decoder have their DecodeRLP method
+// called; everything else is handled by reflection.
+func (s *Stream) Decode(val interface{}) error {
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("rlp: Decode requires a non-nil pointer")
+	}
+	if dec, ok := val.(Decoder); ok {
+		return dec.DecodeRLP(s)
+	}
+	return s.decodeReflect(rv.Elem())
+}
+
+func (s *Stream) decodeReflect(rv reflect.Value) error {
+	if rv.CanAddr() {
+		if dec, ok := rv.Addr().Interface().(Decoder); ok {
+			return dec.DecodeRLP(s)
+		}
+	}
+	switch rv.Kind() {
+	case reflect.String:
+		b, err := s.Bytes()
+		if err != nil {
+			return err
+		}
+		rv.SetString(string(b))
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x, err := s.Uint64()
+		if err != nil {
+			return err
+		}
+		rv.SetUint(x)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x, err := s.Uint64()
+		if err != nil {
+			return err
+		}
+		rv.SetInt(int64(x))
+		return nil
+	case reflect.Bool:
+		x, err := s.Uint64()
+		if err != nil {
+			return err
+		}
+		rv.SetBool(x != 0)
+		return nil
+	case reflect.Slice, reflect.Array:
+		if isByteSlice(rv.Type()) {
+			b, err := s.Bytes()
+			if err != nil {
+				return err
+			}
+			return setByteSlice(rv, b)
+		}
+		return s.decodeList(rv)
+	case reflect.Ptr:
+		return s.decodePtr(rv)
+	case reflect.Struct:
+		return s.decodeStruct(rv)
+	default:
+		return fmt.Errorf("rlp: unsupported type %s", rv.Type())
+	}
+}
+
+func setByteSlice(rv reflect.Value, b []byte) error {
+	if rv.Kind() == reflect.Array {
+		if len(b) != rv.Len() {
+			return fmt.Errorf("rlp: expected %d bytes, got %d", rv.Len(), len(b))
+		}
+		reflect.Copy(rv, reflect.ValueOf(b))
+		return nil
+	}
+	rv.SetBytes(b)
+	return nil
+}
+
+// decodeTail reads the remaining items of the list the caller already
+// entered with List into fv, a slice field tagged rlp:"tail". Unlike
+// decodeList, it does not open a list of its own: encodeStruct flattens
+// a tail field's elements directly into the enclosing list, so decoding
+// them has to keep reading straight from that same list's payload
+// rather than expecting a nested sub-list header.
+func (s *Stream) decodeTail(rv reflect.Value) error {
+	var elems []reflect.Value
+	for s.More() {
+		ev := reflect.New(rv.Type().Elem()).Elem()
+		if err := s.decodeReflect(ev); err != nil {
+			return err
+		}
+		elems = append(elems, ev)
+	}
+	out := reflect.MakeSlice(rv.Type(), len(elems), len(elems))
+	for i, ev := range elems {
+		out.Index(i).Set(ev)
+	}
+	rv.Set(out)
+	return nil
+}
+
+func (s *Stream) decodeList(rv reflect.Value) error {
+	if _, err := s.List(); err != nil {
+		return err
+	}
+	var elems []reflect.Value
+	for s.More() {
+		ev := reflect.New(rv.Type().Elem()).Elem()
+		if err := s.decodeReflect(ev); err != nil {
+			return err
+		}
+		elems = append(elems, ev)
+	}
+	if err := s.ListEnd(); err != nil {
+		return err
+	}
+	out := reflect.MakeSlice(rv.Type(), len(elems), len(elems))
+	for i, ev := range elems {
+		out.Index(i).Set(ev)
+	}
+	rv.Set(out)
+	return nil
+}
+
+// decodePtr enters the list that every pointer-to-struct field encodes
+// as (see encodeStruct's "nil" tag: a nil pointer is an empty list, a
+// non-nil one is the pointee's own field list). An empty list therefore
+// always means nil, which is why decodePtr does not need an explicit
+// "was this tagged nil" signal from the caller.
+func (s *Stream) decodePtr(rv reflect.Value) error {
+	size, err := s.List()
+	if err != nil {
+		return err
+	}
+	if size == 0 {
+		return s.ListEnd()
+	}
+	rv.Set(reflect.New(rv.Type().Elem()))
+	if err := s.decodeStructBody(rv.Elem()); err != nil {
+		return err
+	}
+	return s.ListEnd()
+}
+
+func (s *Stream) decodeStruct(rv reflect.Value) error {
+	if _, err := s.List(); err != nil {
+		return err
+	}
+	if err := s.decodeStructBody(rv); err != nil {
+		return err
+	}
+	return s.ListEnd()
+}
+
+// decodeStructBody reads rv's fields assuming the enclosing list has
+// already been entered with List; the caller is responsible for the
+// matching ListEnd.
+func (s *Stream) decodeStructBody(rv reflect.Value) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag := parseTag(f.Tag.Get("rlp"))
+		fv := rv.Field(i)
+
+		if tag.tail {
+			return s.decodeTail(fv)
+		}
+		if tag.optional && !s.More() {
+			continue
+		}
+		if !s.More() {
+			return fmt.Errorf("rlp: too few elements for struct %s", t.Name())
+		}
+		if err := s.decodeReflect(fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readByte reads a single byte without accounting for it; callers charge
+// the item's full size against the enclosing list themselves.
+func (s *Stream) readByte() (byte, error) {
+	var b [1]byte
+	if _, err := io.ReadFull(s.r, b[:]); err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// readHeader parses the length header for a prefix byte b that used
+// shortBase/longBase ranges, returning the number of header bytes
+// consumed beyond the prefix byte b itself, and the payload size. A
+// long-form header whose size would have fit in short form, or whose
+// length field has a leading zero byte, is rejected as non-canonical.
+func (s *Stream) readHeader(b, shortBase, longBase byte) (headerLen int, size uint64, err error) {
+	if b < shortBase+56 {
+		size = uint64(b - shortBase)
+		return 0, size, s.checkFits(0, size)
+	}
+	nLen := int(b) - int(longBase)
+	lb := make([]byte, nLen)
+	if _, err := io.ReadFull(s.r, lb); err != nil {
+		return 0, 0, err
+	}
+	if lb[0] == 0 {
+		return 0, 0, ErrNonCanonicalSize
+	}
+	var sz uint64
+	for _, c := range lb {
+		sz = sz<<8 | uint64(c)
+	}
+	if sz < 56 {
+		return 0, 0, ErrNonCanonicalSize
+	}
+	return nLen, sz, s.checkFits(nLen, sz)
+}
+
+// checkFits returns ErrElemTooLarge if an item with headerLen extra
+// header bytes and a payload of size bytes claims more than remains of
+// the list on top of the stack, if any.
+func (s *Stream) checkFits(headerLen int, size uint64) error {
+	if len(s.stack) == 0 {
+		return nil
+	}
+	avail := s.stack[len(s.stack)-1].remaining - int64(headerLen) - 1
+	if avail < 0 || size > uint64(avail) {
+		return ErrElemTooLarge
+	}
+	return nil
+}
+
+// account records n consumed bytes (header plus payload) against the
+// list currently on top of the stack, if any, so ListEnd can detect a
+// short or long read.
+func (s *Stream) account(n int64) {
+	if len(s.stack) == 0 {
+		return
+	}
+	s.stack[len(s.stack)-1].remaining -= n
+}
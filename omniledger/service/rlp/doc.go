@@ -0,0 +1,26 @@
+// Package rlp implements Recursive Length Prefix encoding, the scheme
+// Ethereum uses to serialize transactions. It exists so omniledger's
+// Instruction, StateChange, and ClientTransaction types can be hashed and
+// transmitted the same way regardless of the language a client is
+// written in, which protobuf's reflection-based wire format does not
+// guarantee.
+//
+// Encoding walks the value once: each struct and list is encoded into its
+// own buffer first, and its length prefix is then chosen from the
+// buffered size. EncodeToBytes makes no separate pass that computes
+// payload lengths ahead of time. Decoding uses
+// a stack-based Stream that tracks how many payload bytes remain in
+// each list it has entered, and rejects non-canonical encodings (leading
+// zero integers, length prefixes used where a direct encoding would have
+// fit) the same way go-ethereum's rlp package does.
+//
+// Struct fields are encoded in declaration order. Tags in the "rlp"
+// struct tag change that:
+//
+//	rlp:"nil"      a nil pointer encodes as an empty list (an untagged nil
+//	               pointer encodes the same way)
+//	rlp:"optional" the field, and any optional field after it, is omitted
+//	               from the encoding when it holds its zero value
+//	rlp:"tail"     the field must be a slice and consumes every remaining
+//	               item of the enclosing list; it must be the last field
+package rlp
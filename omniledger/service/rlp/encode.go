@@ -0,0 +1,270 @@
+package rlp
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+)
+
+// encodeValue dispatches on the dynamic type of val and writes its RLP
+// encoding to buf.
+func encodeValue(buf *bytes.Buffer, val interface{}) error {
+	if enc, ok := val.(Encoder); ok {
+		return enc.EncodeRLP(buf)
+	}
+	rv := reflect.ValueOf(val)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			writeList(buf, nil)
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	return encodeReflect(buf, rv)
+}
+
+func encodeReflect(buf *bytes.Buffer, rv reflect.Value) error {
+	switch rv.Kind() {
+	case reflect.String:
+		writeString(buf, []byte(rv.String()))
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		writeUint(buf, rv.Uint())
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.Int() < 0 {
+			return fmt.Errorf("rlp: cannot encode negative integer %d", rv.Int())
+		}
+		writeUint(buf, uint64(rv.Int()))
+		return nil
+	case reflect.Bool:
+		if rv.Bool() {
+			writeUint(buf, 1)
+		} else {
+			writeUint(buf, 0)
+		}
+		return nil
+	case reflect.Slice, reflect.Array:
+		if isByteSlice(rv.Type()) {
+			writeString(buf, byteSliceOf(rv))
+			return nil
+		}
+		items := make([][]byte, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			var item bytes.Buffer
+			if err := encodeReflectOrEncoder(&item, rv.Index(i)); err != nil {
+				return err
+			}
+			items[i] = item.Bytes()
+		}
+		writeList(buf, items)
+		return nil
+	case reflect.Ptr:
+		if rv.IsNil() {
+			writeList(buf, nil)
+			return nil
+		}
+		return encodeReflect(buf, rv.Elem())
+	case reflect.Struct:
+		return encodeStruct(buf, rv)
+	default:
+		return fmt.Errorf("rlp: unsupported type %s", rv.Type())
+	}
+}
+
+func encodeReflectOrEncoder(buf *bytes.Buffer, rv reflect.Value) error {
+	if rv.CanInterface() {
+		if enc, ok := rv.Interface().(Encoder); ok {
+			return enc.EncodeRLP(buf)
+		}
+	}
+	return encodeReflect(buf, rv)
+}
+
+// encodeStruct walks the exported fields of rv in declaration order,
+// honouring the "rlp" struct tag: "nil" encodes a nil pointer field as
+// an empty list (as an untagged nil pointer is too), "optional" allows a run of
+// trailing zero-valued fields to be dropped entirely, and "tail" spreads
+// a slice field's elements directly into the enclosing list instead of
+// nesting them in their own list.
+func encodeStruct(buf *bytes.Buffer, rv reflect.Value) error {
+	t := rv.Type()
+	var items [][]byte
+	firstOptional := -1
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+		tag := parseTag(f.Tag.Get("rlp"))
+		fv := rv.Field(i)
+
+		if tag.tail {
+			if fv.Kind() != reflect.Slice {
+				return fmt.Errorf("rlp: rlp:\"tail\" field %s must be a slice", f.Name)
+			}
+			for j := 0; j < fv.Len(); j++ {
+				var item bytes.Buffer
+				if err := encodeReflectOrEncoder(&item, fv.Index(j)); err != nil {
+					return err
+				}
+				items = append(items, item.Bytes())
+			}
+			continue
+		}
+
+		if tag.optional && firstOptional < 0 {
+			firstOptional = len(items)
+		}
+		if !tag.optional {
+			firstOptional = -1
+		}
+
+		if tag.nilable && fv.Kind() == reflect.Ptr && fv.IsNil() {
+			items = append(items, encodeEmptyList())
+			continue
+		}
+
+		var item bytes.Buffer
+		if err := encodeReflectOrEncoder(&item, fv); err != nil {
+			return err
+		}
+		items = append(items, item.Bytes())
+	}
+	if firstOptional >= 0 {
+		items = trimTrailingZero(items, firstOptional)
+	}
+	writeList(buf, items)
+	return nil
+}
+
+// trimTrailingZero drops items at index >= from that are the canonical
+// empty encoding (0x80 or 0xc0), stopping at the first non-empty one.
+func trimTrailingZero(items [][]byte, from int) [][]byte {
+	end := len(items)
+	for end > from && isEmptyEncoding(items[end-1]) {
+		end--
+	}
+	return items[:end]
+}
+
+func isEmptyEncoding(b []byte) bool {
+	return len(b) == 1 && (b[0] == 0x80 || b[0] == 0xc0)
+}
+
+func encodeEmptyList() []byte {
+	return []byte{0xc0}
+}
+
+type rlpTag struct {
+	nilable  bool
+	optional bool
+	tail     bool
+}
+
+func parseTag(s string) rlpTag {
+	var t rlpTag
+	for _, part := range splitComma(s) {
+		switch part {
+		case "nil":
+			t.nilable = true
+		case "optional":
+			t.optional = true
+		case "tail":
+			t.tail = true
+		}
+	}
+	return t
+}
+
+func splitComma(s string) []string {
+	var out []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == ',' {
+			out = append(out, s[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(s) {
+		out = append(out, s[start:])
+	}
+	return out
+}
+
+func isByteSlice(t reflect.Type) bool {
+	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() == reflect.Uint8
+}
+
+func byteSliceOf(rv reflect.Value) []byte {
+	if rv.Kind() == reflect.Array {
+		b := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(b), rv)
+		return b
+	}
+	return rv.Bytes()
+}
+
+// writeString writes the RLP encoding of a byte string: a single byte in
+// [0x00, 0x7f] encodes itself, anything else gets a length prefix built
+// from 0x80 (short, len < 56) or 0xb7 (long).
+func writeString(buf *bytes.Buffer, b []byte) {
+	if len(b) == 1 && b[0] < 0x80 {
+		buf.WriteByte(b[0])
+		return
+	}
+	writeLengthPrefix(buf, 0x80, 0xb7, len(b))
+	buf.Write(b)
+}
+
+// writeUint encodes x as the shortest big-endian byte string with no
+// leading zero byte, per writeString's rules (so 0 encodes as 0x80, the
+// empty string).
+func writeUint(buf *bytes.Buffer, x uint64) {
+	if x == 0 {
+		writeString(buf, nil)
+		return
+	}
+	var b [8]byte
+	n := 8
+	for x > 0 {
+		n--
+		b[n] = byte(x)
+		x >>= 8
+	}
+	writeString(buf, b[n:])
+}
+
+// writeList writes the RLP encoding of a list whose items have already
+// been individually encoded into items.
+func writeList(buf *bytes.Buffer, items [][]byte) {
+	total := 0
+	for _, it := range items {
+		total += len(it)
+	}
+	writeLengthPrefix(buf, 0xc0, 0xf7, total)
+	for _, it := range items {
+		buf.Write(it)
+	}
+}
+
+// writeLengthPrefix emits the length prefix for a payload of size n,
+// using shortBase (string: 0x80, list: 0xc0) directly when n < 56, or
+// longBase (string: 0xb7, list: 0xf7) plus a big-endian length field
+// otherwise.
+func writeLengthPrefix(buf *bytes.Buffer, shortBase, longBase byte, n int) {
+	if n < 56 {
+		buf.WriteByte(shortBase + byte(n))
+		return
+	}
+	var lb [8]byte
+	ln := 8
+	x := n
+	for x > 0 {
+		ln--
+		lb[ln] = byte(x)
+		x >>= 8
+	}
+	buf.WriteByte(longBase + byte(8-ln))
+	buf.Write(lb[ln:])
+}
@@ -0,0 +1,74 @@
+package rlp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// Kind identifies the shape of the next value a Stream will read:
+// a single byte in [0x00, 0x7f], a byte string, or a list.
+type Kind int
+
+// The three shapes an RLP item can take.
+const (
+	Byte Kind = iota
+	String
+	List
+)
+
+// Encoder is implemented by types that know how to write their own RLP
+// encoding. Encode calls EncodeRLP instead of reflecting over the value
+// when it is available.
+type Encoder interface {
+	EncodeRLP(io.Writer) error
+}
+
+// Decoder is implemented by types that know how to read their own RLP
+// encoding from a Stream. Decode calls DecodeRLP instead of reflecting
+// over the value when it is available.
+type Decoder interface {
+	DecodeRLP(*Stream) error
+}
+
+// ErrNonCanonicalSize is returned when a length prefix uses more bytes,
+// or a larger value, than the canonical encoding requires.
+var ErrNonCanonicalSize = errors.New("rlp: non-canonical size")
+
+// ErrNonCanonicalInt is returned when a single-byte-or-less integer is
+// encoded using a length prefix instead of its direct form.
+var ErrNonCanonicalInt = errors.New("rlp: non-canonical integer (leading zero bytes)")
+
+// Encode writes the RLP encoding of val to w.
+func Encode(w io.Writer, val interface{}) error {
+	buf, err := EncodeToBytes(val)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+// EncodeToBytes returns the RLP encoding of val. It walks val once,
+// encoding every nested struct and slice into its own buffer and then
+// choosing that item's length prefix from the buffered size; there is no
+// separate pass that precomputes sizes.
+func EncodeToBytes(val interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeValue(&buf, val); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode reads the RLP encoding of a single value from r into val, which
+// must be a non-nil pointer.
+func Decode(r io.Reader, val interface{}) error {
+	s := NewStream(r, 0)
+	return s.Decode(val)
+}
+
+// DecodeBytes parses the RLP encoding of a single value from b into val.
+func DecodeBytes(b []byte, val interface{}) error {
+	return Decode(bytes.NewReader(b), val)
+}
@@ -0,0 +1,147 @@
+package rlp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type simple struct {
+	A uint64
+	B string
+	C []byte
+}
+
+type withNilField struct {
+	Name  string
+	Child *simple `rlp:"nil"`
+}
+
+type withOptionalTail struct {
+	Required uint64
+	Extra    uint64 `rlp:"optional"`
+}
+
+type withTail struct {
+	Head uint64
+	Rest []uint64 `rlp:"tail"`
+}
+
+func TestRoundTripSimpleStruct(t *testing.T) {
+	in := simple{A: 1234, B: "hello", C: []byte{1, 2, 3}}
+
+	buf, err := EncodeToBytes(in)
+	require.NoError(t, err)
+
+	var out simple
+	require.NoError(t, DecodeBytes(buf, &out))
+	require.Equal(t, in, out)
+}
+
+func TestRoundTripNilPointerField(t *testing.T) {
+	in := withNilField{Name: "leaf"}
+
+	buf, err := EncodeToBytes(in)
+	require.NoError(t, err)
+
+	var out withNilField
+	require.NoError(t, DecodeBytes(buf, &out))
+	require.Equal(t, in, out)
+	require.Nil(t, out.Child)
+}
+
+func TestRoundTripNonNilPointerField(t *testing.T) {
+	in := withNilField{Name: "leaf", Child: &simple{A: 1, B: "x"}}
+
+	buf, err := EncodeToBytes(in)
+	require.NoError(t, err)
+
+	var out withNilField
+	require.NoError(t, DecodeBytes(buf, &out))
+	require.Equal(t, in, out)
+}
+
+func TestRoundTripOptionalTrailingFieldDropped(t *testing.T) {
+	in := withOptionalTail{Required: 7}
+
+	buf, err := EncodeToBytes(in)
+	require.NoError(t, err)
+
+	var out withOptionalTail
+	require.NoError(t, DecodeBytes(buf, &out))
+	require.Equal(t, in, out)
+}
+
+type withLeadingNestedStruct struct {
+	Head  simple
+	Nonce uint64
+	Name  string
+	Tags  []uint64
+}
+
+func TestRoundTripLeadingNestedStructField(t *testing.T) {
+	// Mirrors Instruction's shape: a nested struct (ObjectID) is the
+	// *first* field, followed by several scalar and slice siblings. List
+	// previously only charged its header bytes against the enclosing
+	// list, leaving the nested struct's payload bytes undebited once it
+	// popped back off -- every sibling read after it would then consume
+	// the wrong bytes.
+	in := withLeadingNestedStruct{
+		Head:  simple{A: 1234, B: "hello", C: []byte{1, 2, 3}},
+		Nonce: 7,
+		Name:  "leaf",
+		Tags:  []uint64{2, 3, 4},
+	}
+
+	buf, err := EncodeToBytes(in)
+	require.NoError(t, err)
+
+	var out withLeadingNestedStruct
+	require.NoError(t, DecodeBytes(buf, &out))
+	require.Equal(t, in, out)
+}
+
+func TestRoundTripTailField(t *testing.T) {
+	in := withTail{Head: 1, Rest: []uint64{2, 3, 4}}
+
+	buf, err := EncodeToBytes(in)
+	require.NoError(t, err)
+
+	var out withTail
+	require.NoError(t, DecodeBytes(buf, &out))
+	require.Equal(t, in, out)
+}
+
+func TestDecodeRejectsNonCanonicalLeadingZeroInt(t *testing.T) {
+	// A length-one string whose single byte is < 0x80 must be encoded as
+	// that byte directly (writeString's fast path); wrapping it in a
+	// one-byte-string header (0x81, 0x00) is the non-canonical form
+	// go-ethereum's rlp rejects, since it would let the same integer
+	// decode from two different byte sequences.
+	var out uint64
+	err := DecodeBytes([]byte{0x81, 0x00}, &out)
+	require.Equal(t, ErrNonCanonicalInt, err)
+}
+
+func TestDecodeRejectsNonCanonicalLongLengthPrefix(t *testing.T) {
+	// A short string (4 bytes) encoded with a long-form length prefix
+	// (0xb8 takes a 1-byte length field) instead of the short form
+	// (0x80 + len) that fits the same size. The string is wrapped in the
+	// single-field list a struct{S string} decodes as.
+	err := DecodeBytes([]byte{0xc6, 0xb8, 0x04, 'a', 'b', 'c', 'd'}, &struct{ S string }{})
+	require.Equal(t, ErrNonCanonicalSize, err)
+}
+
+func TestDecodeRejectsLengthFieldWithLeadingZero(t *testing.T) {
+	// A long-form length prefix whose length field itself starts with a
+	// zero byte -- here claiming a 2-byte length field of [0x00, 0x38]
+	// (56) where a 1-byte field would have sufficed. Wrapped in the
+	// single-field list a struct{S string} decodes as.
+	err := DecodeBytes([]byte{0xc3, 0xb9, 0x00, 0x38}, &struct{ S string }{})
+	require.Equal(t, ErrNonCanonicalSize, err)
+}
+
+func TestEncodeRejectsNegativeInt(t *testing.T) {
+	_, err := EncodeToBytes(struct{ N int }{N: -1})
+	require.Error(t, err)
+}
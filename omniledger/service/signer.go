@@ -0,0 +1,179 @@
+package service
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/dedis/cothority/omniledger/darc"
+)
+
+// Signer computes the digest an Instruction is signed over and recovers
+// the identity that produced a given signature. Splitting this out of
+// Instruction.Hash lets a skipchain choose, and later change, the rules
+// that bind a signature to a single chain -- the same role
+// types.Signer/LatestSignerForChainID play for go-ethereum transactions.
+type Signer interface {
+	// Hash returns the instruction digest that is placed in the
+	// darc.Request whose hash signatures over instr are computed against.
+	Hash(instr Instruction) []byte
+	// ChainID returns the chain identifier this signer mixes into Hash,
+	// or nil for the legacy signer.
+	ChainID() []byte
+	// Sender recovers the identity that authorized instr, by verifying
+	// its signatures against the hash of the darc.Request built around
+	// Hash, and returning the first one that checks out.
+	Sender(instr Instruction) (darc.Identity, error)
+}
+
+// ChainConfig describes the signer a skipchain is using at a given block
+// height, and any scheduled upgrade to a new one. It is stored as part of
+// the genesis darc config.
+type ChainConfig struct {
+	// ChainID identifies this skipchain for replay-protection purposes.
+	// It is typically the hash of the genesis skipblock.
+	ChainID []byte
+	// SignerFork maps a block index to the name of the signer that
+	// becomes active at that height (inclusive). Below the lowest entry,
+	// or with no entries, MakeSigner uses "eip155" when ChainID is set and
+	// "legacy" otherwise.
+	SignerFork map[int]string
+	// RLPDigest selects Instruction.HashRLP over Instruction.Hash as the
+	// chain's canonical instruction digest. See HashForConfig.
+	RLPDigest bool
+}
+
+// legacySignerName is used for instructions with a zero ChainID, so that
+// transactions signed before chain IDs existed keep verifying.
+const legacySignerName = "legacy"
+
+// eip155SignerName is the name under which NewEIP155Signer registers
+// itself for use in a ChainConfig.SignerFork.
+const eip155SignerName = "eip155"
+
+var signerRegistry = struct {
+	sync.Mutex
+	factories map[string]func(chainID []byte, rlpDigest bool) Signer
+}{factories: make(map[string]func(chainID []byte, rlpDigest bool) Signer)}
+
+// RegisterSigner makes a signer scheme available to MakeSigner under
+// name. It is typically called from an init function.
+func RegisterSigner(name string, factory func(chainID []byte, rlpDigest bool) Signer) {
+	signerRegistry.Lock()
+	defer signerRegistry.Unlock()
+	signerRegistry.factories[name] = factory
+}
+
+func init() {
+	RegisterSigner(legacySignerName, func(_ []byte, rlpDigest bool) Signer {
+		return legacySigner{rlpDigest: rlpDigest}
+	})
+	RegisterSigner(eip155SignerName, func(chainID []byte, rlpDigest bool) Signer {
+		return NewEIP155Signer(chainID, rlpDigest)
+	})
+}
+
+// MakeSigner returns the Signer that is active for cfg at blockIdx,
+// picking the most recent entry of cfg.SignerFork whose key is <=
+// blockIdx. If no entry applies, it uses the eip155 signer when
+// cfg.ChainID is set, and the legacy signer when it is empty, preserving
+// backward compatibility for chains created before chain IDs existed.
+func MakeSigner(cfg ChainConfig, blockIdx int) (Signer, error) {
+	name := legacySignerName
+	if len(cfg.ChainID) > 0 {
+		name = eip155SignerName
+	}
+	best := -1
+	for idx, n := range cfg.SignerFork {
+		if idx <= blockIdx && idx > best {
+			best = idx
+			name = n
+		}
+	}
+
+	signerRegistry.Lock()
+	factory, ok := signerRegistry.factories[name]
+	signerRegistry.Unlock()
+	if !ok {
+		return nil, errors.New("service: unknown signer scheme " + name)
+	}
+	return factory(cfg.ChainID, cfg.RLPDigest), nil
+}
+
+// legacySigner reproduces the original Instruction.Hash digest, which did
+// not mix in a chain ID. MakeSigner selects it for a ChainConfig with an
+// empty ChainID and no applicable SignerFork entry. For an Instruction
+// with a zero ChainID it computes the same digest as the eip155 signer.
+type legacySigner struct {
+	rlpDigest bool
+}
+
+// Hash implements Signer.
+func (s legacySigner) Hash(instr Instruction) []byte {
+	instr.ChainID = [32]byte{}
+	return HashForConfig(instr, ChainConfig{RLPDigest: s.rlpDigest})
+}
+
+// ChainID implements Signer.
+func (legacySigner) ChainID() []byte { return nil }
+
+// Sender implements Signer.
+func (s legacySigner) Sender(instr Instruction) (darc.Identity, error) {
+	return senderFromSignatures(instr, s)
+}
+
+// eip155Signer mixes a 32-byte ChainID into the instruction digest, so a
+// signature produced for one skipchain cannot be replayed against
+// another that happens to share the same darc and nonce space.
+type eip155Signer struct {
+	chainID   []byte
+	rlpDigest bool
+}
+
+// NewEIP155Signer returns a Signer bound to chainID, using the RLP
+// digest instead of Instruction.Hash when rlpDigest is set. It is the
+// replay-protected counterpart to the legacy signer, and is what
+// SignerFork entries should point new chains, or chain upgrades, at.
+func NewEIP155Signer(chainID []byte, rlpDigest bool) Signer {
+	return eip155Signer{chainID: chainID, rlpDigest: rlpDigest}
+}
+
+// Hash implements Signer.
+func (s eip155Signer) Hash(instr Instruction) []byte {
+	copy(instr.ChainID[:], s.chainID)
+	return HashForConfig(instr, ChainConfig{ChainID: s.chainID, RLPDigest: s.rlpDigest})
+}
+
+// ChainID implements Signer.
+func (s eip155Signer) ChainID() []byte { return s.chainID }
+
+// Sender implements Signer.
+func (s eip155Signer) Sender(instr Instruction) (darc.Identity, error) {
+	return senderFromSignatures(instr, s)
+}
+
+// senderFromSignatures returns the identity of the first signature in
+// instr that verifies against the hash of the darc.Request toDarcRequest
+// builds for instr with signer, the same digest SignBy signs. A
+// signature computed over a different digest -- for instance because it
+// was produced for a different ChainID and replayed here -- does not
+// verify and is skipped, so a tx signed for one chain cannot be
+// attributed to a sender on another.
+func senderFromSignatures(instr Instruction, signer Signer) (darc.Identity, error) {
+	if len(instr.Signatures) == 0 {
+		return darc.Identity{}, errors.New("service: instruction has no signatures")
+	}
+	req, err := instr.toDarcRequest(signer)
+	if err != nil {
+		return darc.Identity{}, err
+	}
+	digest := req.Hash()
+	if len(digest) == 0 {
+		return darc.Identity{}, errors.New("service: empty digest")
+	}
+	for _, sig := range instr.Signatures {
+		if err := sig.Signer.Verify(digest, sig.Signature); err == nil {
+			return sig.Signer, nil
+		}
+	}
+	return darc.Identity{}, errors.New("service: no signature verifies against this digest")
+}
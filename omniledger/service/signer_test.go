@@ -0,0 +1,70 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/dedis/cothority/omniledger/darc"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEIP155SignerRejectsChainReplay signs an instruction for chain A and
+// checks that it verifies on chain A but not when the exact same
+// instruction is re-submitted against chain B.
+func TestEIP155SignerRejectsChainReplay(t *testing.T) {
+	signer := darc.NewSignerEd25519(nil, nil)
+
+	var chainA, chainB [32]byte
+	chainA[0] = 0xaa
+	chainB[0] = 0xbb
+
+	instr := Instruction{
+		ObjectID: ObjectID{DarcID: darc.ID("darc-under-test")},
+		ChainID:  chainA,
+	}
+	require.NoError(t, instr.SignBy(signer))
+
+	signerA, err := MakeSigner(ChainConfig{ChainID: chainA[:]}, 0)
+	require.NoError(t, err)
+	sender, err := signerA.Sender(instr)
+	require.NoError(t, err, "a tx signed for chain A must verify on chain A")
+	wantIdentity := signer.Identity()
+	require.True(t, sender.Equal(&wantIdentity))
+
+	signerB, err := MakeSigner(ChainConfig{ChainID: chainB[:]}, 0)
+	require.NoError(t, err)
+	_, err = signerB.Sender(instr)
+	require.Error(t, err, "a tx signed for chain A must not verify on chain B")
+}
+
+// TestMakeSignerRLPDigestUsesRLPHash checks that a ChainConfig with
+// RLPDigest set produces a Signer whose Hash matches Instruction.HashRLP
+// rather than Instruction.Hash, so a chain can select the RLP codec as
+// its canonical digest the way ChainConfig.RLPDigest documents.
+func TestMakeSignerRLPDigestUsesRLPHash(t *testing.T) {
+	instr := Instruction{ObjectID: ObjectID{DarcID: darc.ID("darc-under-test")}}
+
+	plain, err := MakeSigner(ChainConfig{}, 0)
+	require.NoError(t, err)
+	require.Equal(t, instr.Hash(), plain.Hash(instr))
+
+	rlpSigner, err := MakeSigner(ChainConfig{RLPDigest: true}, 0)
+	require.NoError(t, err)
+	require.Equal(t, instr.HashRLP(), rlpSigner.Hash(instr))
+	require.NotEqual(t, plain.Hash(instr), rlpSigner.Hash(instr))
+}
+
+// TestLegacySignerUnaffectedByChainID checks that an Instruction with a
+// zero ChainID, signed by SignBy, verifies under the legacy signer that
+// MakeSigner returns for an empty ChainConfig: a zero ChainID is not mixed
+// into the digest, so it does not change what the signature covers.
+func TestLegacySignerUnaffectedByChainID(t *testing.T) {
+	signer := darc.NewSignerEd25519(nil, nil)
+
+	instr := Instruction{ObjectID: ObjectID{DarcID: darc.ID("darc-under-test")}}
+	require.NoError(t, instr.SignBy(signer))
+
+	legacy, err := MakeSigner(ChainConfig{}, 0)
+	require.NoError(t, err)
+	_, err = legacy.Sender(instr)
+	require.NoError(t, err)
+}
@@ -12,7 +12,6 @@ import (
 	"github.com/dedis/onet/log"
 	"github.com/dedis/onet/network"
 
-	"github.com/dedis/cothority/omniledger/collection"
 	"github.com/dedis/cothority/omniledger/darc"
 	"github.com/dedis/protobuf"
 )
@@ -46,12 +45,18 @@ type Instruction struct {
 	Index int
 	// Length is the total number of instructions in this clientTransaction
 	Length int
+	// ChainID identifies the skipchain this instruction was signed for.
+	// A zero ChainID selects the legacy signing scheme for backward
+	// compatibility; any other value is mixed into Hash so a signature
+	// valid on one skipchain cannot be replayed on another. See
+	// MakeSigner for how a chain picks its active scheme.
+	ChainID [32]byte
 	// Spawn creates a new object
-	Spawn *Spawn
+	Spawn *Spawn `rlp:"nil"`
 	// Invoke calls a method of an existing object
-	Invoke *Invoke
+	Invoke *Invoke `rlp:"nil"`
 	// Delete removes the given object
-	Delete *Delete
+	Delete *Delete `rlp:"nil"`
 	// Signatures that can be verified using the darc defined by the objectID.
 	Signatures []darc.Signature
 }
@@ -120,7 +125,10 @@ func (args Arguments) Search(name string) []byte {
 	return nil
 }
 
-// Hash computes the digest of the hash function
+// Hash computes the digest of the hash function. If ChainID is non-zero
+// it is mixed in last, so a signature computed over this digest on one
+// skipchain cannot be replayed as-is on another skipchain that happens
+// to share the same darc IDs and nonce space.
 func (instr Instruction) Hash() []byte {
 	h := sha256.New()
 	h.Write(instr.ObjectID.DarcID)
@@ -147,6 +155,9 @@ func (instr Instruction) Hash() []byte {
 		h.Write([]byte(a.Name))
 		h.Write(a.Value)
 	}
+	if instr.ChainID != ([32]byte{}) {
+		h.Write(instr.ChainID[:])
+	}
 	return h.Sum(nil)
 }
 
@@ -173,6 +184,10 @@ func (instr Instruction) DeriveID(what string) ObjectID {
 
 // GetContractState searches for the contract kind of this instruction and the
 // attached state to it. It needs the collection to do so.
+//
+// Deprecated: use a Context built with NewContext and call its Read
+// method instead. GetContractState is kept only so contracts that have
+// not migrated to the Context/Action API keep compiling.
 func (instr Instruction) GetContractState(coll CollectionView) (contractID string, state []byte, err error) {
 	// Getting the kind is different for instructions that create a key
 	// and for instructions that send a call to an existing key.
@@ -183,21 +198,7 @@ func (instr Instruction) GetContractState(coll CollectionView) (contractID strin
 
 	// For existing keys, we need to go look the kind up in our database
 	// to find the kind.
-	kv := coll.Get(instr.ObjectID.Slice())
-	var record collection.Record
-	record, err = kv.Record()
-	if err != nil {
-		return
-	}
-	var cv []interface{}
-	cv, err = record.Values()
-	if err != nil {
-		return
-	}
-	// TODO cast might panic
-	contractID = string(cv[1].([]byte))
-	state = cv[0].([]byte)
-	return
+	return readObject(coll, instr.ObjectID)
 }
 
 // Action returns the action that the user wants to do with this
@@ -263,8 +264,45 @@ func (instr *Instruction) SignBy(signers ...darc.Signer) error {
 	return nil
 }
 
-// ToDarcRequest converts the Instruction content into a darc.Request.
+// ToDarcRequest converts the Instruction content into a darc.Request,
+// using the signer active for instr.ChainID at block 0. It is what
+// SignBy uses to build the request it signs, which is the one caller
+// for whom deriving the ChainConfig from instr itself is correct: a
+// client is declaring which chain it intends to sign for, not verifying
+// someone else's claim. Anything checking a signature instr did not
+// just produce -- in particular the block proposer and verifier --
+// must call ToDarcRequestAtHeight with the chain's own ChainConfig
+// instead, never this method.
 func (instr Instruction) ToDarcRequest() (*darc.Request, error) {
+	return instr.ToDarcRequestAtHeight(ChainConfig{ChainID: instr.ChainID[:]}, 0)
+}
+
+// ToDarcRequestAtHeight is ToDarcRequest, but resolves the active signer
+// from cfg -- the verifying chain's own ChainConfig, which the caller
+// must obtain independently of instr, such as from Context.ChainID --
+// at blockIdx instead of always at block 0, so a ChainConfig.SignerFork
+// upgrade is honored once it has taken effect. instr.ChainID must equal
+// cfg.ChainID: an instruction that claims a different chain than the one
+// actually verifying it is rejected outright, rather than having that
+// claim trusted and its signer re-derived from it, which would let a
+// signature produced for one chain replay on another.
+func (instr Instruction) ToDarcRequestAtHeight(cfg ChainConfig, blockIdx int) (*darc.Request, error) {
+	var wantChainID [32]byte
+	copy(wantChainID[:], cfg.ChainID)
+	if instr.ChainID != wantChainID {
+		return nil, errors.New("service: instruction's ChainID does not match the verifying chain")
+	}
+	signer, err := MakeSigner(cfg, blockIdx)
+	if err != nil {
+		return nil, err
+	}
+	return instr.toDarcRequest(signer)
+}
+
+// toDarcRequest is the signer-parameterized core of ToDarcRequestAtHeight.
+// It does not compare instr.ChainID with signer; ToDarcRequestAtHeight
+// does that before calling it.
+func (instr Instruction) toDarcRequest(signer Signer) (*darc.Request, error) {
 	baseID := instr.ObjectID.DarcID
 	action := instr.Action()
 	ids := make([]darc.Identity, len(instr.Signatures))
@@ -285,7 +323,7 @@ func (instr Instruction) ToDarcRequest() (*darc.Request, error) {
 		}
 		req = darc.InitRequest(baseID, darc.Action(action), d.GetID(), ids, sigs)
 	} else {
-		req = darc.InitRequest(baseID, darc.Action(action), instr.Hash(), ids, sigs)
+		req = darc.InitRequest(baseID, darc.Action(action), signer.Hash(instr), ids, sigs)
 	}
 	return &req, nil
 }
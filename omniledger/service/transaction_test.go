@@ -0,0 +1,56 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/dedis/cothority/omniledger/darc"
+	"github.com/stretchr/testify/require"
+)
+
+// TestToDarcRequestAtHeightRejectsChainIDMismatch drives the replay check
+// through ToDarcRequestAtHeight itself, the entry point a verifier
+// actually calls, rather than around it via MakeSigner: an instruction
+// signed for chain A must be rejected outright when checked against
+// chain B's ChainConfig, not have chain A's signer silently re-derived
+// from the instruction's own (attacker-controlled) ChainID field.
+func TestToDarcRequestAtHeightRejectsChainIDMismatch(t *testing.T) {
+	signer := darc.NewSignerEd25519(nil, nil)
+
+	var chainA, chainB [32]byte
+	chainA[0] = 0xaa
+	chainB[0] = 0xbb
+
+	instr := Instruction{
+		ObjectID: ObjectID{DarcID: darc.ID("darc-under-test")},
+		ChainID:  chainA,
+	}
+	require.NoError(t, instr.SignBy(signer))
+
+	_, err := instr.ToDarcRequestAtHeight(ChainConfig{ChainID: chainA[:]}, 0)
+	require.NoError(t, err, "an instruction must verify against the chain it was signed for")
+
+	_, err = instr.ToDarcRequestAtHeight(ChainConfig{ChainID: chainB[:]}, 0)
+	require.Error(t, err, "an instruction signed for chain A must be rejected outright when checked against chain B, not re-verified using a signer derived from instr.ChainID")
+}
+
+// TestToDarcRequestRejectsForgedChainID checks that ToDarcRequest -- the
+// client-side helper SignBy uses, which trusts instr.ChainID because the
+// client is declaring its own target chain -- still can't be used to
+// smuggle a mismatched ChainID past ToDarcRequestAtHeight: building the
+// request against a ChainConfig for a different chain than instr claims
+// must fail.
+func TestToDarcRequestRejectsForgedChainID(t *testing.T) {
+	var chainA [32]byte
+	chainA[0] = 0xaa
+
+	instr := Instruction{
+		ObjectID: ObjectID{DarcID: darc.ID("darc-under-test")},
+		ChainID:  chainA,
+	}
+
+	_, err := instr.ToDarcRequest()
+	require.NoError(t, err)
+
+	_, err = instr.ToDarcRequestAtHeight(ChainConfig{}, 0)
+	require.Error(t, err, "an instruction carrying a non-zero ChainID must not verify against a ChainConfig for a different (here: legacy/zero) chain")
+}
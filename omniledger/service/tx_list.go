@@ -0,0 +1,103 @@
+package service
+
+import (
+	"sort"
+)
+
+// darcKey is the string form of a darc.ID, used to index transactions by
+// the darc that authorizes them.
+type darcKey string
+
+func keyOf(id []byte) darcKey {
+	return darcKey(id)
+}
+
+// txSlot wraps a ClientTransaction with the nonce that determines its
+// ordering: the nonce of its leading instruction, whose ObjectID.DarcID
+// is also the darc Add files the whole transaction under. Later
+// instructions of the transaction play no part in its placement.
+type txSlot struct {
+	tx    ClientTransaction
+	nonce Nonce
+}
+
+// txList keeps the transactions belonging to a single darc ID sorted by
+// nonce. It is used both for the pending set (contiguous nonces starting
+// at the next expected one) and the queued set (nonces with a gap before
+// them).
+//
+// txList is not safe for concurrent use; callers must hold TxPool.mu.
+type txList struct {
+	slots map[Nonce]txSlot
+}
+
+func newTxList() *txList {
+	return &txList{slots: make(map[Nonce]txSlot)}
+}
+
+// Get returns the transaction stored at nonce, if any.
+func (l *txList) Get(nonce Nonce) (ClientTransaction, bool) {
+	s, ok := l.slots[nonce]
+	return s.tx, ok
+}
+
+// Put stores tx under nonce, silently overwriting any previous occupant
+// without any bookkeeping. A caller that might replace an existing slot
+// (see TxPool.Add, which calls takeExisting and forgets the occupant
+// first) is responsible for that accounting; Put itself does not track
+// it.
+func (l *txList) Put(nonce Nonce, tx ClientTransaction) {
+	l.slots[nonce] = txSlot{tx: tx, nonce: nonce}
+}
+
+// Remove deletes the transaction stored at nonce, if any.
+func (l *txList) Remove(nonce Nonce) {
+	delete(l.slots, nonce)
+}
+
+// Len returns the number of transactions held by the list.
+func (l *txList) Len() int {
+	return len(l.slots)
+}
+
+// Nonces returns the nonces held by the list, sorted in ascending order.
+func (l *txList) Nonces() []Nonce {
+	out := make([]Nonce, 0, len(l.slots))
+	for n := range l.slots {
+		out = append(out, n)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return lessNonce(out[i], out[j])
+	})
+	return out
+}
+
+// Flatten returns the transactions held by the list, ordered by nonce.
+func (l *txList) Flatten() ClientTransactions {
+	nonces := l.Nonces()
+	out := make(ClientTransactions, len(nonces))
+	for i, n := range nonces {
+		out[i] = l.slots[n].tx
+	}
+	return out
+}
+
+// lowestPriority returns the nonce furthest from head, which is the
+// natural eviction candidate when a per-account or global slot limit is
+// exceeded: the transaction least likely to be executable soon.
+func (l *txList) lowestPriority() (Nonce, bool) {
+	nonces := l.Nonces()
+	if len(nonces) == 0 {
+		return Nonce{}, false
+	}
+	return nonces[len(nonces)-1], true
+}
+
+func lessNonce(a, b Nonce) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
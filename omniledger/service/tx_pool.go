@@ -0,0 +1,374 @@
+package service
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/dedis/onet/log"
+)
+
+// TxPoolConfig bounds the memory a TxPool is allowed to use. The zero value
+// of TxPoolConfig is not usable; use DefaultTxPoolConfig.
+type TxPoolConfig struct {
+	// AccountSlots is the maximum number of queued-plus-pending
+	// transactions kept for a single darc.
+	AccountSlots int
+	// GlobalSlots is the maximum number of transactions kept across all
+	// darcs. Once reached, Add evicts one queued transaction (see
+	// evictLowestPriority) to make room for the new one, without
+	// comparing their priorities, and fails if nothing is queued.
+	GlobalSlots int
+}
+
+// DefaultTxPoolConfig uses the same per-account and global slot limits
+// as go-ethereum's core.TxPool defaults.
+var DefaultTxPoolConfig = TxPoolConfig{
+	AccountSlots: 16,
+	GlobalSlots:  4096,
+}
+
+// TxPoolStats summarizes the current occupancy of a TxPool.
+type TxPoolStats struct {
+	Pending int
+	Queued  int
+}
+
+// TxPool is a staging area for ClientTransactions that have been gossiped
+// to this node but not yet included in a block. It keeps two sets of
+// transactions per darc, indexed by the signing darc's ID:
+//
+//   - pending: the instruction's Nonce is exactly the next nonce expected
+//     for that darc. These are immediately executable and are what
+//     Pending returns to the block proposer.
+//   - queued: the instruction's Nonce is ahead of the next expected
+//     nonce. These are held until reset closes the gap, or they are
+//     evicted by a slot limit.
+//
+// TxPool is safe for concurrent use.
+type TxPool struct {
+	mu sync.Mutex
+
+	pending map[darcKey]*txList
+	queued  map[darcKey]*txList
+
+	// seen de-duplicates by Instruction.Hash() across both pending and
+	// queued, so a re-gossiped transaction is rejected cheaply.
+	seen map[string]bool
+
+	// nextNonce is the next nonce this pool expects for a given darc. It
+	// is advanced by reset() whenever a block is committed.
+	nextNonce map[darcKey]Nonce
+
+	cfg TxPoolConfig
+
+	subs   map[chan ClientTransaction]struct{}
+	nTotal int
+}
+
+// NewTxPool returns an empty TxPool configured with cfg.
+func NewTxPool(cfg TxPoolConfig) *TxPool {
+	return &TxPool{
+		pending:   make(map[darcKey]*txList),
+		queued:    make(map[darcKey]*txList),
+		seen:      make(map[string]bool),
+		nextNonce: make(map[darcKey]Nonce),
+		cfg:       cfg,
+		subs:      make(map[chan ClientTransaction]struct{}),
+	}
+}
+
+// Add validates tx and stages it in the pending or queued set depending on
+// whether its leading instruction's nonce matches the darc's next expected
+// nonce. It rejects duplicates (by Instruction.Hash) and enforces the
+// per-account and global slot limits configured on the pool.
+func (p *TxPool) Add(tx ClientTransaction) error {
+	if len(tx.Instructions) == 0 {
+		return errors.New("tx_pool: transaction has no instructions")
+	}
+	instr := tx.Instructions[0]
+	key := keyOf(instr.ObjectID.DarcID)
+	hash := string(instr.Hash())
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.seen[hash] {
+		return errors.New("tx_pool: duplicate transaction")
+	}
+
+	// A transaction already occupies (key, instr.Nonce) -- e.g. a client
+	// rebroadcasting with a tweaked instruction. It is about to be
+	// overwritten, so release its accounting first: replacing a slot
+	// must not count against the account/global limits below, and must
+	// not leave the replaced tx's hash stuck in p.seen forever.
+	if old, existed := p.takeExisting(key, instr.Nonce); existed {
+		p.forget(old)
+	} else {
+		if n := p.accountLen(key); n >= p.cfg.AccountSlots {
+			return errors.New("tx_pool: account slot limit reached")
+		}
+		if p.nTotal >= p.cfg.GlobalSlots {
+			if !p.evictLowestPriority() {
+				return errors.New("tx_pool: global slot limit reached")
+			}
+		}
+	}
+
+	p.seen[hash] = true
+	p.nTotal++
+
+	if p.isNextNonce(key, instr.Nonce) {
+		p.listFor(p.pending, key).Put(instr.Nonce, tx)
+		p.notify(tx)
+		return nil
+	}
+	p.listFor(p.queued, key).Put(instr.Nonce, tx)
+	return nil
+}
+
+// takeExisting removes and returns whatever transaction currently
+// occupies (key, nonce), in either the pending or queued set.
+func (p *TxPool) takeExisting(key darcKey, nonce Nonce) (ClientTransaction, bool) {
+	if l, ok := p.pending[key]; ok {
+		if tx, ok := l.Get(nonce); ok {
+			l.Remove(nonce)
+			return tx, true
+		}
+	}
+	if l, ok := p.queued[key]; ok {
+		if tx, ok := l.Get(nonce); ok {
+			l.Remove(nonce)
+			return tx, true
+		}
+	}
+	return ClientTransaction{}, false
+}
+
+// ProposeBlock is the entry point a block proposer calls in place of
+// receiving raw transactions from the network layer: it takes the
+// current pending set and orders it with sortTransactions, so the
+// resulting slice is both executable (every instruction's nonce is ready)
+// and unpredictable to any single party ahead of time.
+func (p *TxPool) ProposeBlock() (ClientTransactions, error) {
+	txs := p.Pending()
+	if err := sortTransactions(txs); err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+// Pending returns all transactions currently eligible for inclusion in
+// the next block, across all darcs. The block proposer calls this
+// instead of receiving raw transactions directly from the network layer.
+func (p *TxPool) Pending() ClientTransactions {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var out ClientTransactions
+	for _, l := range p.pending {
+		out = append(out, l.Flatten()...)
+	}
+	return out
+}
+
+// Stats reports the current pool occupancy.
+func (p *TxPool) Stats() TxPoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var s TxPoolStats
+	for _, l := range p.pending {
+		s.Pending += l.Len()
+	}
+	for _, l := range p.queued {
+		s.Queued += l.Len()
+	}
+	return s
+}
+
+// SubscribeNewTxs registers a channel that receives every transaction as
+// it is promoted into the pending set, either by Add or by Reset. The
+// caller must drain the channel; Unsubscribe removes it again.
+func (p *TxPool) SubscribeNewTxs() chan ClientTransaction {
+	ch := make(chan ClientTransaction, 16)
+	p.mu.Lock()
+	p.subs[ch] = struct{}{}
+	p.mu.Unlock()
+	return ch
+}
+
+// Unsubscribe removes a channel previously returned by SubscribeNewTxs.
+func (p *TxPool) Unsubscribe(ch chan ClientTransaction) {
+	p.mu.Lock()
+	delete(p.subs, ch)
+	p.mu.Unlock()
+}
+
+// Reset is called by the service's block-commit hook after a new block
+// is accepted. It advances the next expected nonce for every darc touched
+// by committed, drops the now-stale entries that reference those nonces
+// or earlier, and promotes any queued transactions whose gap has closed
+// as a result. ProposeBlock's output is only correct if Reset has been
+// called for every block since the pool was created.
+//
+// oldHead and newHead are accepted for parity with the block-proposer's
+// view of the chain; the pool itself only needs the set of instructions
+// that were actually committed to decide what to promote or drop.
+func (p *TxPool) Reset(oldHead, newHead []byte, committed ClientTransactions) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, tx := range committed {
+		for _, instr := range tx.Instructions {
+			key := keyOf(instr.ObjectID.DarcID)
+			p.advanceNonce(key, instr.Nonce)
+			p.dropStale(key, instr.Nonce)
+		}
+	}
+	for key := range p.queued {
+		p.promote(key)
+	}
+}
+
+// accountLen returns the combined pending+queued count for key.
+func (p *TxPool) accountLen(key darcKey) int {
+	n := 0
+	if l, ok := p.pending[key]; ok {
+		n += l.Len()
+	}
+	if l, ok := p.queued[key]; ok {
+		n += l.Len()
+	}
+	return n
+}
+
+func (p *TxPool) listFor(m map[darcKey]*txList, key darcKey) *txList {
+	l, ok := m[key]
+	if !ok {
+		l = newTxList()
+		m[key] = l
+	}
+	return l
+}
+
+func (p *TxPool) isNextNonce(key darcKey, nonce Nonce) bool {
+	next, ok := p.nextNonce[key]
+	if !ok {
+		// No block has ever been committed for this darc; accept
+		// nonce 0 as the starting point.
+		return nonce == (Nonce{})
+	}
+	return nonce == next
+}
+
+// advanceNonce records that nonce has been committed for key, and sets
+// the darc's next expected nonce to nonce+1.
+func (p *TxPool) advanceNonce(key darcKey, nonce Nonce) {
+	next := incNonce(nonce)
+	cur, ok := p.nextNonce[key]
+	if !ok || lessNonce(cur, next) {
+		p.nextNonce[key] = next
+	}
+}
+
+// dropStale removes any pending or queued entry for key at or before
+// nonce: it has either just been committed, or has been superseded by a
+// later instruction from the same darc in the same block.
+func (p *TxPool) dropStale(key darcKey, nonce Nonce) {
+	if l, ok := p.pending[key]; ok {
+		p.removeUpTo(l, nonce)
+	}
+	if l, ok := p.queued[key]; ok {
+		p.removeUpTo(l, nonce)
+	}
+}
+
+func (p *TxPool) removeUpTo(l *txList, nonce Nonce) {
+	for _, n := range l.Nonces() {
+		if !lessNonce(nonce, n) {
+			if tx, ok := l.Get(n); ok {
+				p.forget(tx)
+			}
+			l.Remove(n)
+		}
+	}
+}
+
+// promote moves every queued transaction for key whose nonce has become
+// executable into the pending set, stopping at the first remaining gap.
+func (p *TxPool) promote(key darcKey) {
+	l, ok := p.queued[key]
+	if !ok {
+		return
+	}
+	for {
+		next := p.nextNonce[key]
+		tx, ok := l.Get(next)
+		if !ok {
+			return
+		}
+		l.Remove(next)
+		p.listFor(p.pending, key).Put(next, tx)
+		p.nextNonce[key] = incNonce(next)
+		p.notify(tx)
+	}
+}
+
+// evictLowestPriority drops one transaction from the account currently
+// holding the most queued transactions, favouring pending transactions
+// of other accounts over it. It returns false if nothing could be
+// evicted.
+func (p *TxPool) evictLowestPriority() bool {
+	var worstKey darcKey
+	worstLen := -1
+	for key, l := range p.queued {
+		if l.Len() > worstLen {
+			worstLen = l.Len()
+			worstKey = key
+		}
+	}
+	if worstLen <= 0 {
+		log.Lvl2("tx_pool: no queued transaction available to evict")
+		return false
+	}
+	l := p.queued[worstKey]
+	nonce, ok := l.lowestPriority()
+	if !ok {
+		return false
+	}
+	if tx, ok := l.Get(nonce); ok {
+		p.forget(tx)
+	}
+	l.Remove(nonce)
+	return true
+}
+
+func (p *TxPool) forget(tx ClientTransaction) {
+	if len(tx.Instructions) == 0 {
+		return
+	}
+	delete(p.seen, string(tx.Instructions[0].Hash()))
+	p.nTotal--
+}
+
+func (p *TxPool) notify(tx ClientTransaction) {
+	for ch := range p.subs {
+		select {
+		case ch <- tx:
+		default:
+			log.Lvl2("tx_pool: subscriber channel full, dropping notification")
+		}
+	}
+}
+
+// incNonce returns nonce+1, treating the 32 bytes as a big-endian
+// unsigned integer.
+func incNonce(nonce Nonce) Nonce {
+	n := new(big.Int).SetBytes(nonce[:])
+	n.Add(n, big.NewInt(1))
+	b := n.Bytes()
+	var out Nonce
+	copy(out[len(out)-len(b):], b)
+	return out
+}
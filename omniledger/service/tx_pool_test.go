@@ -0,0 +1,110 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func txWithNonce(darcID []byte, nonce Nonce) ClientTransaction {
+	return ClientTransaction{Instructions: Instructions{{
+		ObjectID: ObjectID{DarcID: darcID},
+		Nonce:    nonce,
+	}}}
+}
+
+func nonceOf(n byte) Nonce {
+	var out Nonce
+	out[31] = n
+	return out
+}
+
+func TestTxPoolPendingAndQueued(t *testing.T) {
+	p := NewTxPool(DefaultTxPoolConfig)
+	d := []byte{1}
+
+	require.NoError(t, p.Add(txWithNonce(d, nonceOf(1)))) // future nonce -> queued
+	stats := p.Stats()
+	require.Equal(t, 0, stats.Pending)
+	require.Equal(t, 1, stats.Queued)
+
+	require.NoError(t, p.Add(txWithNonce(d, nonceOf(0)))) // next expected -> pending
+	stats = p.Stats()
+	require.Equal(t, 1, stats.Pending)
+	require.Equal(t, 1, stats.Queued)
+}
+
+func TestTxPoolRejectsDuplicate(t *testing.T) {
+	p := NewTxPool(DefaultTxPoolConfig)
+	tx := txWithNonce([]byte{1}, nonceOf(0))
+	require.NoError(t, p.Add(tx))
+	require.Error(t, p.Add(tx))
+}
+
+func TestTxPoolReplaceAtSameSlotForgetsOldOccupant(t *testing.T) {
+	p := NewTxPool(DefaultTxPoolConfig)
+	d := []byte{1}
+	n := nonceOf(1)
+
+	tx1 := txWithNonce(d, n)
+	require.NoError(t, p.Add(tx1))
+	require.Equal(t, 1, p.Stats().Queued)
+
+	// A different transaction lands on the same (darc, nonce) slot, as
+	// happens when a client rebroadcasts with a tweaked instruction. It
+	// must replace tx1 rather than being rejected, and the occupancy
+	// count must not grow because of the replacement.
+	tx2 := txWithNonce(d, n)
+	tx2.Instructions[0].Index = 1 // give tx2 a different Hash from tx1
+	require.NoError(t, p.Add(tx2))
+	require.Equal(t, 1, p.Stats().Queued)
+
+	// tx1's hash must have been released, so resubmitting it verbatim
+	// succeeds instead of being rejected as a stale duplicate.
+	require.NoError(t, p.Add(tx1))
+}
+
+func TestTxPoolReplaceDoesNotExhaustGlobalSlots(t *testing.T) {
+	cfg := TxPoolConfig{AccountSlots: 10, GlobalSlots: 1}
+	p := NewTxPool(cfg)
+	d := []byte{1}
+	n := nonceOf(0)
+
+	tx1 := txWithNonce(d, n)
+	require.NoError(t, p.Add(tx1))
+
+	// With GlobalSlots == 1, a second *new* slot would be rejected (or
+	// would have to evict). Replacing the single existing slot must
+	// keep working indefinitely.
+	for i := byte(0); i < 5; i++ {
+		tx := txWithNonce(d, n)
+		tx.Instructions[0].Index = int(i) + 1
+		require.NoError(t, p.Add(tx))
+	}
+	require.Equal(t, 1, p.Stats().Pending)
+}
+
+func TestTxPoolResetPromotesQueued(t *testing.T) {
+	p := NewTxPool(DefaultTxPoolConfig)
+	d := []byte{1}
+
+	require.NoError(t, p.Add(txWithNonce(d, nonceOf(1)))) // queued, gap at nonce 0
+	require.Equal(t, 1, p.Stats().Queued)
+
+	committed := ClientTransactions{txWithNonce(d, nonceOf(0))}
+	p.Reset(nil, nil, committed)
+
+	stats := p.Stats()
+	require.Equal(t, 1, stats.Pending, "closing the gap should promote the queued tx")
+	require.Equal(t, 0, stats.Queued)
+}
+
+func TestTxPoolProposeBlockReturnsPendingSorted(t *testing.T) {
+	p := NewTxPool(DefaultTxPoolConfig)
+	require.NoError(t, p.Add(txWithNonce([]byte{1}, nonceOf(0))))
+	require.NoError(t, p.Add(txWithNonce([]byte{2}, nonceOf(0))))
+
+	txs, err := p.ProposeBlock()
+	require.NoError(t, err)
+	require.Len(t, txs, 2)
+}
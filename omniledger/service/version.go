@@ -0,0 +1,20 @@
+package service
+
+import "github.com/dedis/onet/log"
+
+// Version is the release this binary was built from. It is the zero
+// release unless a program assigns it; being a struct, it cannot be set
+// with go build -ldflags -X.
+var Version = ReleaseVersion{}
+
+// warnIfOutdated logs a warning if latest is newer than the compiled-in
+// Version, so an operator notices a release published through the
+// release contract even if they never query it directly.
+func warnIfOutdated(latest ReleaseVersion) {
+	if Version.Less(latest) {
+		log.Warnf("running version %d.%d.%d.%d is older than the latest "+
+			"published release %d.%d.%d.%d -- consider upgrading",
+			Version.Major, Version.Minor, Version.Patch, Version.Build,
+			latest.Major, latest.Minor, latest.Patch, latest.Build)
+	}
+}